@@ -442,6 +442,14 @@ func main() {
 			cmd.WaitForCloudDeploy()
 			return
 		}
+		// Usage sinks only run with the proxy service, not for one-shot commands.
+		usage.SetInfluxDBConfig(cfg.UsageInfluxDB)
+		usage.SetWebhookConfig(cfg.UsageWebhook)
+		usage.SetKafkaConfig(cfg.UsageKafka)
+		usage.SetOTLPConfig(cfg.UsageOTLP)
+		usage.SetAuditLogConfig(cfg.UsageAuditLog)
+		usage.LoadExternalPlugins(cfg.UsagePluginDir)
+
 		// Start the main proxy service
 		managementasset.StartAutoUpdater(context.Background(), configFilePath)
 		cmd.StartService(cfg, configFilePath, password)
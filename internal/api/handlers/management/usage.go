@@ -2,11 +2,29 @@ package management
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
 )
 
+const (
+	tailWriteTimeout     = 10 * time.Second
+	tailHeartbeatPeriod  = 30 * time.Second
+	tailSubscriberBuffer = 256
+)
+
+var tailUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
 // GetUsageStatistics returns the in-memory request statistics snapshot.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
 	var snapshot usage.StatisticsSnapshot
@@ -18,3 +36,81 @@ func (h *Handler) GetUsageStatistics(c *gin.Context) {
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// GetUsageMetrics renders the live usage counters in the Prometheus text exposition format.
+func (h *Handler) GetUsageMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	var collector *usage.PrometheusCollector
+	if h != nil {
+		collector = h.usageMetrics
+	}
+	_, _ = collector.WriteTo(c.Writer)
+}
+
+// GetUsagePlugins reports the runtime counters of the usage sinks so that
+// delivery failures are visible without reading the logs.
+func (h *Handler) GetUsagePlugins(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"plugins": usage.PluginStatuses()})
+}
+
+// GetUsageTail upgrades to a WebSocket and streams each completed request as it
+// is recorded. Optional query filters: provider, model, api_key, failed=true.
+func (h *Handler) GetUsageTail(c *gin.Context) {
+	filter := usage.TailFilter{
+		Provider:   strings.TrimSpace(c.Query("provider")),
+		Model:      strings.TrimSpace(c.Query("model")),
+		APIKey:     strings.TrimSpace(c.Query("api_key")),
+		FailedOnly: strings.EqualFold(strings.TrimSpace(c.Query("failed")), "true"),
+	}
+
+	conn, err := tailUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Debugf("usage tail: websocket upgrade failed: %v", err)
+		return
+	}
+	defer func() {
+		if errClose := conn.Close(); errClose != nil {
+			log.Debugf("usage tail: close connection error: %v", errClose)
+		}
+	}()
+
+	events, cancel := usage.GetTailBroadcaster().Subscribe(filter, tailSubscriberBuffer)
+	defer cancel()
+
+	// Drain inbound frames so close and pong control messages are processed.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, errRead := conn.ReadMessage(); errRead != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(tailHeartbeatPeriod)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if errPing := conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(tailWriteTimeout)); errPing != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if errDeadline := conn.SetWriteDeadline(time.Now().Add(tailWriteTimeout)); errDeadline != nil {
+				return
+			}
+			if errWrite := conn.WriteJSON(event); errWrite != nil {
+				return
+			}
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -305,6 +306,7 @@ func (s *Server) setupRoutes() {
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		v1.GET("/usage", s.callerUsageHandler)
 	}
 
 	// Gemini compatible API routes
@@ -435,6 +437,9 @@ func (s *Server) registerManagementRoutes() {
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.GET("/usage/metrics", s.mgmt.GetUsageMetrics)
+		mgmt.GET("/usage/plugins", s.mgmt.GetUsagePlugins)
+		mgmt.GET("/usage/tail", s.mgmt.GetUsageTail)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigFile)
@@ -652,6 +657,71 @@ func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, cl
 	}
 }
 
+// callerUsageHandler reports the in-memory usage recorded for the API key that
+// authenticated the request, so clients can check their own consumption
+// without management access.
+func (s *Server) callerUsageHandler(c *gin.Context) {
+	apiKey := strings.TrimSpace(c.GetString("apiKey"))
+	if apiKey == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usage is only available to requests authenticated with an API key"})
+		return
+	}
+
+	type modelUsage struct {
+		TotalRequests int64            `json:"total_requests"`
+		FailureCount  int64            `json:"failure_count"`
+		Tokens        usage.TokenStats `json:"tokens"`
+	}
+	var (
+		successCount int64
+		failureCount int64
+		tokens       usage.TokenStats
+	)
+	models := make(map[string]modelUsage)
+	requestsByDay := make(map[string]int64)
+	tokensByDay := make(map[string]int64)
+
+	snapshot, _ := usage.GetRequestStatistics().APISnapshot(apiKey)
+	for modelName, modelSnapshot := range snapshot.Models {
+		entry := modelUsage{TotalRequests: modelSnapshot.TotalRequests}
+		for _, detail := range modelSnapshot.Details {
+			if detail.Failed {
+				entry.FailureCount++
+				failureCount++
+			} else {
+				successCount++
+			}
+			entry.Tokens.InputTokens += detail.Tokens.InputTokens
+			entry.Tokens.OutputTokens += detail.Tokens.OutputTokens
+			entry.Tokens.ReasoningTokens += detail.Tokens.ReasoningTokens
+			entry.Tokens.CachedTokens += detail.Tokens.CachedTokens
+			entry.Tokens.TotalTokens += detail.Tokens.TotalTokens
+
+			day := detail.Timestamp.Format("2006-01-02")
+			requestsByDay[day]++
+			tokensByDay[day] += detail.Tokens.TotalTokens
+		}
+		tokens.InputTokens += entry.Tokens.InputTokens
+		tokens.OutputTokens += entry.Tokens.OutputTokens
+		tokens.ReasoningTokens += entry.Tokens.ReasoningTokens
+		tokens.CachedTokens += entry.Tokens.CachedTokens
+		tokens.TotalTokens += entry.Tokens.TotalTokens
+		models[modelName] = entry
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_key":                  util.HideAPIKey(apiKey),
+		"usage_statistics_enabled": usage.StatisticsEnabled(),
+		"total_requests":           snapshot.TotalRequests,
+		"success_count":            successCount,
+		"failure_count":            failureCount,
+		"tokens":                   tokens,
+		"models":                   models,
+		"requests_by_day":          requestsByDay,
+		"tokens_by_day":            tokensByDay,
+	})
+}
+
 // Start begins listening for and serving HTTP requests.
 // It's a blocking call and will only return on an unrecoverable error.
 //
@@ -772,6 +842,35 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageInfluxDB, cfg.UsageInfluxDB) {
+		usage.SetInfluxDBConfig(cfg.UsageInfluxDB)
+		log.Debugf("usage-influxdb settings updated (enabled: %t)", cfg.UsageInfluxDB.Enable)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageWebhook, cfg.UsageWebhook) {
+		usage.SetWebhookConfig(cfg.UsageWebhook)
+		log.Debugf("usage-webhook settings updated (enabled: %t)", cfg.UsageWebhook.Enable)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageKafka, cfg.UsageKafka) {
+		usage.SetKafkaConfig(cfg.UsageKafka)
+		log.Debugf("usage-kafka settings updated (enabled: %t)", cfg.UsageKafka.Enable)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageOTLP, cfg.UsageOTLP) {
+		usage.SetOTLPConfig(cfg.UsageOTLP)
+		log.Debugf("usage-otlp settings updated (enabled: %t)", cfg.UsageOTLP.Enable)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageAuditLog, cfg.UsageAuditLog) {
+		usage.SetAuditLogConfig(cfg.UsageAuditLog)
+		log.Debugf("usage-audit-log settings updated (enabled: %t)", cfg.UsageAuditLog.Enable)
+	}
+
+	if oldCfg != nil && oldCfg.UsagePluginDir != cfg.UsagePluginDir {
+		log.Warn("usage-plugin-dir changed; restart the server to load external usage plugins")
+	}
+
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 		if oldCfg != nil {
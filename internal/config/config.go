@@ -34,6 +34,26 @@ type Config struct {
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageInfluxDB forwards usage records to an InfluxDB v2 bucket using line protocol.
+	UsageInfluxDB UsageInfluxDB `yaml:"usage-influxdb" json:"usage-influxdb"`
+
+	// UsageWebhook posts usage records to an external HTTP endpoint.
+	UsageWebhook UsageWebhook `yaml:"usage-webhook" json:"usage-webhook"`
+
+	// UsageKafka publishes usage records to a Kafka topic.
+	UsageKafka UsageKafka `yaml:"usage-kafka" json:"usage-kafka"`
+
+	// UsageOTLP exports usage metrics to an OpenTelemetry collector over OTLP/HTTP.
+	UsageOTLP UsageOTLP `yaml:"usage-otlp" json:"usage-otlp"`
+
+	// UsageAuditLog appends usage records to a local, hash-chained JSONL audit log.
+	UsageAuditLog UsageAuditLog `yaml:"usage-audit-log" json:"usage-audit-log"`
+
+	// UsagePluginDir is a directory of WebAssembly (.wasm) and Go plugin (.so) files
+	// loaded at startup as additional usage plugins. Go plugins need a cgo build.
+	// Changes take effect after a restart.
+	UsagePluginDir string `yaml:"usage-plugin-dir" json:"usage-plugin-dir"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -75,6 +95,214 @@ type RemoteManagement struct {
 	DisableControlPanel bool `yaml:"disable-control-panel"`
 }
 
+// UsageInfluxDB holds the InfluxDB v2 write settings under 'usage-influxdb'.
+type UsageInfluxDB struct {
+	// Enable toggles forwarding of usage records to InfluxDB.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// URL is the InfluxDB base URL, e.g. http://localhost:8086.
+	URL string `yaml:"url" json:"url"`
+
+	// Org is the organization that owns the target bucket.
+	Org string `yaml:"org" json:"org"`
+
+	// Bucket is the destination bucket for usage points.
+	Bucket string `yaml:"bucket" json:"bucket"`
+
+	// Token is the API token sent as "Authorization: Token <token>".
+	Token string `yaml:"token" json:"token"`
+
+	// Measurement overrides the measurement name. Defaults to "cliproxy_usage".
+	Measurement string `yaml:"measurement,omitempty" json:"measurement,omitempty"`
+
+	// BatchSize is the number of points buffered before a write is triggered. Defaults to 100.
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+
+	// FlushInterval is the maximum number of seconds points stay buffered. Defaults to 10.
+	FlushInterval int `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+
+	// Compression selects the request body encoding. Supported: "" (none) and "gzip";
+	// InfluxDB does not decode zstd, so "zstd" falls back to gzip.
+	Compression string `yaml:"compression,omitempty" json:"compression,omitempty"`
+
+	// MaxPayloadBytes caps the uncompressed size of a single write request.
+	// Larger batches are split across several requests. Zero disables the cap.
+	MaxPayloadBytes int `yaml:"max-payload-bytes,omitempty" json:"max-payload-bytes,omitempty"`
+
+	// Pipeline filters and transforms the records handed to this sink.
+	Pipeline UsagePipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// UsageWebhook holds the webhook delivery settings under 'usage-webhook'.
+type UsageWebhook struct {
+	// Enable toggles webhook delivery of usage records.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// URL is the endpoint that receives POST requests with JSON usage payloads.
+	URL string `yaml:"url" json:"url"`
+
+	// Secret signs each payload with HMAC-SHA256 in the X-CLIProxy-Signature header when set.
+	Secret string `yaml:"secret" json:"secret"`
+
+	// Headers optionally adds extra HTTP headers to every delivery.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// BatchSize groups records into JSON arrays of up to this many entries.
+	// Values of 0 or 1 deliver each record as a single JSON object.
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+
+	// FlushInterval is the maximum number of seconds a partial batch waits. Defaults to 5.
+	FlushInterval int `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+
+	// MaxRetries is the number of retries after a transport error, a 5xx or a 429
+	// response. Other 4xx responses are never retried. Defaults to 3 when unset;
+	// 0 disables retries.
+	MaxRetries *int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+
+	// DeadLetterFile receives payloads that still fail after all retries, and records
+	// dropped from a full buffer, one per line.
+	DeadLetterFile string `yaml:"dead-letter-file,omitempty" json:"dead-letter-file,omitempty"`
+
+	// Compression selects the request body encoding. Supported: "" (none), "gzip" and "zstd".
+	// The signature is computed over the uncompressed body.
+	Compression string `yaml:"compression,omitempty" json:"compression,omitempty"`
+
+	// MaxPayloadBytes caps the uncompressed size of a single batched request.
+	// Larger batches are split across several requests. Zero disables the cap.
+	MaxPayloadBytes int `yaml:"max-payload-bytes,omitempty" json:"max-payload-bytes,omitempty"`
+
+	// Pipeline filters and transforms the records handed to this sink.
+	Pipeline UsagePipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// UsageKafka holds the Kafka producer settings under 'usage-kafka'.
+type UsageKafka struct {
+	// Enable toggles publishing of usage records to Kafka.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Brokers lists the bootstrap broker addresses (host:port).
+	Brokers []string `yaml:"brokers" json:"brokers"`
+
+	// Topic is the destination topic. Messages are keyed by the hashed API key.
+	Topic string `yaml:"topic" json:"topic"`
+
+	// SASL configures broker authentication.
+	SASL UsageKafkaSASL `yaml:"sasl,omitempty" json:"sasl,omitempty"`
+
+	// TLS configures encrypted broker connections.
+	TLS UsageKafkaTLS `yaml:"tls,omitempty" json:"tls,omitempty"`
+
+	// BatchSize is the maximum number of messages per produce request. Defaults to 100.
+	BatchSize int `yaml:"batch-size,omitempty" json:"batch-size,omitempty"`
+
+	// FlushInterval is the maximum number of seconds a partial batch waits. Defaults to 1.
+	FlushInterval int `yaml:"flush-interval,omitempty" json:"flush-interval,omitempty"`
+
+	// Compression selects the message codec. Supported: "" (none), "gzip", "snappy", "lz4" and "zstd".
+	Compression string `yaml:"compression,omitempty" json:"compression,omitempty"`
+
+	// MaxPayloadBytes caps the size of a single produce request. Defaults to 1 MiB.
+	MaxPayloadBytes int `yaml:"max-payload-bytes,omitempty" json:"max-payload-bytes,omitempty"`
+
+	// Pipeline filters and transforms the records handed to this sink.
+	Pipeline UsagePipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// UsageKafkaSASL holds SASL credentials for the Kafka producer.
+type UsageKafkaSASL struct {
+	// Mechanism is one of "plain", "scram-sha-256" or "scram-sha-512". Empty disables SASL.
+	Mechanism string `yaml:"mechanism" json:"mechanism"`
+
+	// Username is the SASL user name.
+	Username string `yaml:"username" json:"username"`
+
+	// Password is the SASL password.
+	Password string `yaml:"password" json:"password"`
+}
+
+// UsageKafkaTLS holds TLS settings for the Kafka producer.
+type UsageKafkaTLS struct {
+	// Enable turns on TLS for broker connections.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// InsecureSkipVerify disables broker certificate verification.
+	InsecureSkipVerify bool `yaml:"insecure-skip-verify" json:"insecure-skip-verify"`
+}
+
+// UsageOTLP holds the OTLP metrics exporter settings under 'usage-otlp'.
+type UsageOTLP struct {
+	// Enable toggles the OTLP metrics exporter.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Endpoint is the collector URL, e.g. http://otel-collector:4318.
+	// The /v1/metrics path is appended when the URL has no path.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// Headers optionally adds extra HTTP headers to every export request.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ExportInterval is the number of seconds between exports. Defaults to 60.
+	ExportInterval int `yaml:"export-interval,omitempty" json:"export-interval,omitempty"`
+
+	// ServiceName sets the service.name resource attribute. Defaults to "cli-proxy-api".
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+
+	// Pipeline filters and transforms the records handed to this sink.
+	Pipeline UsagePipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// UsageAuditLog holds the JSONL audit log settings under 'usage-audit-log'.
+type UsageAuditLog struct {
+	// Enable toggles the audit log.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// File is the path of the active log file. Defaults to logs/usage-audit.jsonl.
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+
+	// MaxSize is the size in megabytes at which the file is rotated. Defaults to 100.
+	MaxSize int `yaml:"max-size,omitempty" json:"max-size,omitempty"`
+
+	// MaxBackups is the number of rotated files to keep. Zero keeps all of them.
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+
+	// MaxAge is the number of days rotated files are kept. Zero keeps them forever.
+	MaxAge int `yaml:"max-age,omitempty" json:"max-age,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+
+	// RotateInterval additionally rotates the file every given number of hours. Zero disables it.
+	RotateInterval int `yaml:"rotate-interval,omitempty" json:"rotate-interval,omitempty"`
+
+	// Pipeline filters and transforms the records handed to this sink.
+	Pipeline UsagePipeline `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// UsagePipeline filters and transforms the usage records handed to a single sink.
+// Filters are applied first, then sampling, then redaction and labels.
+type UsagePipeline struct {
+	// Providers limits the sink to these providers. Empty matches every provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Models limits the sink to these models. "*" matches any run of characters.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// APIKeys limits the sink to records made with these client API keys.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// SampleRate forwards this fraction of the matching records, between 0 and 1.
+	// Zero (the default) forwards every record.
+	SampleRate float64 `yaml:"sample-rate,omitempty" json:"sample-rate,omitempty"`
+
+	// Redact clears fields before the sink sees them.
+	// Supported: "api-key", "auth-id", "source" and "model".
+	Redact []string `yaml:"redact,omitempty" json:"redact,omitempty"`
+
+	// Labels attaches static labels, such as environment or region, to every record.
+	// The names api_key, failed, model, provider and type are reserved and ignored.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
 // It provides configuration options for automatic failover mechanisms.
 type QuotaExceeded struct {
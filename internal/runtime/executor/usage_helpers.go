@@ -78,6 +78,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			APIKey:      r.apiKey,
 			AuthID:      r.authID,
 			RequestedAt: r.requestedAt,
+			Latency:     time.Since(r.requestedAt),
 			Failed:      failed,
 			Detail:      detail,
 		})
@@ -100,6 +101,7 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			APIKey:      r.apiKey,
 			AuthID:      r.authID,
 			RequestedAt: r.requestedAt,
+			Latency:     time.Since(r.requestedAt),
 			Failed:      false,
 			Detail:      usage.Detail{},
 		})
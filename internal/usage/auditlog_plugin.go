@@ -0,0 +1,301 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultAuditLogFile    = "usage-audit.jsonl"
+	defaultAuditLogMaxSize = 100
+	// auditLogTailWindow is how much of an existing file is read to recover the last hash.
+	auditLogTailWindow = 64 * 1024
+	// auditLogBackupTimeFormat is the timestamp lumberjack puts in backup names.
+	auditLogBackupTimeFormat = "2006-01-02T15-04-05.000"
+)
+
+var defaultAuditLogPlugin = NewAuditLogPlugin()
+
+func init() {
+	coreusage.RegisterPlugin(defaultAuditLogPlugin)
+}
+
+// auditLogEntry is one line of the audit log. PrevHash is the hex SHA-256 of the
+// previous raw line, which chains entries across rotated files.
+type auditLogEntry struct {
+	usagePayload
+	PrevHash string `json:"prev_hash"`
+}
+
+// AuditLogPlugin appends every usage record as a JSON line to a local file that
+// is rotated by size and, optionally, by time. The file is independent of the
+// in-memory statistics and survives restarts.
+type AuditLogPlugin struct {
+	mu         sync.Mutex
+	writer     *lumberjack.Logger
+	stage      *usageStage
+	interval   time.Duration
+	nextRotate time.Time
+	prevHash   string
+	stats      sinkStats
+}
+
+// NewAuditLogPlugin constructs a disabled audit log plugin.
+// Call SetConfig to enable writing.
+func NewAuditLogPlugin() *AuditLogPlugin { return &AuditLogPlugin{} }
+
+// SetAuditLogConfig updates the audit log settings of the shared plugin.
+func SetAuditLogConfig(cfg config.UsageAuditLog) { defaultAuditLogPlugin.SetConfig(cfg) }
+
+// SetConfig closes the current file and reopens the log with the new settings.
+func (p *AuditLogPlugin) SetConfig(cfg config.UsageAuditLog) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.writer != nil {
+		if errClose := p.writer.Close(); errClose != nil {
+			log.Warnf("usage-audit-log: close previous file error: %v", errClose)
+		}
+		p.writer = nil
+	}
+	if !cfg.Enable {
+		return
+	}
+
+	path := strings.TrimSpace(cfg.File)
+	if path == "" {
+		dir := "logs"
+		if base := util.WritablePath(); base != "" {
+			dir = filepath.Join(base, "logs")
+		}
+		path = filepath.Join(dir, defaultAuditLogFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Warnf("usage-audit-log: %v, audit log disabled", err)
+		return
+	}
+	prevHash, err := lastAuditLogHash(path)
+	if err != nil {
+		log.Warnf("usage-audit-log: failed to read existing file, starting a new chain: %v", err)
+	}
+
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultAuditLogMaxSize
+	}
+	p.writer = &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+	}
+	p.stage = newUsageStage("usage-audit-log", cfg.Pipeline)
+	p.prevHash = prevHash
+	p.interval = time.Duration(cfg.RotateInterval) * time.Hour
+	if p.interval > 0 {
+		p.nextRotate = time.Now().Add(p.interval)
+	}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *AuditLogPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.writer == nil {
+		return
+	}
+	record, labels, ok := p.stage.apply(record)
+	if !ok {
+		return
+	}
+
+	p.stats.recordHandled()
+
+	if p.interval > 0 && !time.Now().Before(p.nextRotate) {
+		if err := p.writer.Rotate(); err != nil {
+			log.Warnf("usage-audit-log: scheduled rotation failed: %v", err)
+		}
+		p.nextRotate = time.Now().Add(p.interval)
+	}
+
+	line, err := json.Marshal(auditLogEntry{usagePayload: newUsagePayload(record, labels), PrevHash: p.prevHash})
+	if err != nil {
+		log.Errorf("usage-audit-log: failed to encode record: %v", err)
+		p.stats.recordFailure(1, err)
+		return
+	}
+	if _, err = p.writer.Write(append(line, '\n')); err != nil {
+		log.Errorf("usage-audit-log: failed to write record: %v", err)
+		p.stats.recordFailure(1, err)
+		return
+	}
+	p.prevHash = auditLogHash(line)
+	p.stats.recordFlush(1)
+}
+
+// Close closes the audit log file.
+func (p *AuditLogPlugin) Close(context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.writer == nil {
+		return nil
+	}
+	err := p.writer.Close()
+	p.writer = nil
+	return err
+}
+
+func (p *AuditLogPlugin) status() PluginStatus {
+	p.mu.Lock()
+	enabled := p.writer != nil
+	p.mu.Unlock()
+	return p.stats.snapshot("audit-log", enabled, 0)
+}
+
+func auditLogHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastAuditLogHash returns the hash of the last line in path so that a restarted
+// process continues the existing chain. When the active file is missing or
+// empty, as it is right after a rotation, the newest rotated backup is used.
+func lastAuditLogHash(path string) (string, error) {
+	hash, err := lastLineHash(path)
+	if err != nil || hash != "" {
+		return hash, err
+	}
+	backup, err := newestAuditLogBackup(path)
+	if err != nil || backup == "" {
+		return "", err
+	}
+	return lastLineHash(backup)
+}
+
+// newestAuditLogBackup returns the most recent lumberjack backup of path, named
+// "<name>-<timestamp><ext>" and optionally gzipped, or "" when there is none.
+func newestAuditLogBackup(path string) (string, error) {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var newest string
+	var newestAt time.Time
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		compressed := strings.HasSuffix(name, ".gz")
+		trimmed := strings.TrimSuffix(name, ".gz")
+		if !strings.HasPrefix(trimmed, prefix) || !strings.HasSuffix(trimmed, ext) || len(trimmed) < len(prefix)+len(ext) {
+			continue
+		}
+		at, errParse := time.Parse(auditLogBackupTimeFormat, trimmed[len(prefix):len(trimmed)-len(ext)])
+		if errParse != nil {
+			continue
+		}
+		// Prefer the plain file while lumberjack is still compressing it.
+		if newest == "" || at.After(newestAt) || (at.Equal(newestAt) && !compressed) {
+			newest, newestAt = filepath.Join(dir, name), at
+		}
+	}
+	return newest, nil
+}
+
+// lastLineHash returns the hash of the last line in path, or "" when the file
+// is missing or empty. Gzipped backups are read in full.
+func lastLineHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var data []byte
+	if strings.HasSuffix(path, ".gz") {
+		zr, errGzip := gzip.NewReader(f)
+		if errGzip != nil {
+			return "", errGzip
+		}
+		defer func() {
+			_ = zr.Close()
+		}()
+		reader := bufio.NewReader(zr)
+		for {
+			line, errRead := reader.ReadBytes('\n')
+			if trimmed := bytes.TrimRight(line, "\n"); len(trimmed) > 0 {
+				data = trimmed
+			}
+			if errRead == io.EOF {
+				break
+			}
+			if errRead != nil {
+				return "", errRead
+			}
+		}
+		if len(data) == 0 {
+			return "", nil
+		}
+		return auditLogHash(data), nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := info.Size() - auditLogTailWindow
+	if offset < 0 {
+		offset = 0
+	}
+	data, err = io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return "", err
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return "", nil
+	}
+	if idx := bytes.LastIndexByte(data, '\n'); idx >= 0 {
+		data = data[idx+1:]
+	}
+	return auditLogHash(data), nil
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	log "github.com/sirupsen/logrus"
+)
+
+// zstdEncoder is shared by all sinks; EncodeAll is safe for concurrent use.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+// normaliseCompression validates a configured HTTP content encoding.
+// Unsupported values are reported and fall back to no compression.
+func normaliseCompression(sink, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "gzip", "zstd":
+		return value
+	default:
+		log.Warnf("%s: unsupported compression %q, sending uncompressed", sink, value)
+		return ""
+	}
+}
+
+// compressPayload encodes body with the given content encoding ("", "gzip" or "zstd").
+func compressPayload(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "zstd":
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	default:
+		return body, nil
+	}
+}
+
+// payloadChunks splits items into [start, end) ranges holding at most batchSize
+// items and, when maxBytes is positive, at most maxBytes of payload, counting
+// one separator byte between items. A single oversized item is still sent on
+// its own.
+func payloadChunks(sizes []int, batchSize, maxBytes int) [][2]int {
+	var chunks [][2]int
+	start, size := 0, 0
+	for i, itemSize := range sizes {
+		if i > start {
+			itemSize++ // separator
+		}
+		if i > start && (i-start >= batchSize || (maxBytes > 0 && size+itemSize > maxBytes)) {
+			chunks = append(chunks, [2]int{start, i})
+			start, size, itemSize = i, 0, sizes[i]
+		}
+		size += itemSize
+	}
+	if start < len(sizes) {
+		chunks = append(chunks, [2]int{start, len(sizes)})
+	}
+	return chunks
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	externalMu      sync.Mutex
+	externalPlugins []*WASMPlugin
+)
+
+// LoadExternalPlugins loads every .wasm and .so file in dir, in lexical order,
+// and registers it on the default usage manager. WASM modules work in every
+// build; Go plugins need a cgo build on Linux, macOS or FreeBSD. Files that
+// fail to load are logged and skipped. Plugins cannot be unloaded, so this is
+// meant to be called once at startup.
+func LoadExternalPlugins(dir string) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Warnf("usage-plugin-dir: %v", err)
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		var (
+			p       coreusage.Plugin
+			errLoad error
+		)
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".wasm":
+			var wasm *WASMPlugin
+			if wasm, errLoad = LoadWASMPlugin(path); errLoad == nil {
+				externalMu.Lock()
+				externalPlugins = append(externalPlugins, wasm)
+				externalMu.Unlock()
+				p = wasm
+			}
+		case ".so":
+			p, errLoad = loadNativePlugin(path)
+		default:
+			continue
+		}
+		if errLoad != nil {
+			log.Errorf("usage-plugin-dir: failed to load %s: %v", path, errLoad)
+			continue
+		}
+		coreusage.RegisterPlugin(p)
+		log.Infof("usage-plugin-dir: loaded usage plugin %s", name)
+	}
+}
+
+// externalStatuses reports the runtime counters of the loaded WASM plugins.
+func externalStatuses() []PluginStatus {
+	externalMu.Lock()
+	defer externalMu.Unlock()
+	statuses := make([]PluginStatus, 0, len(externalPlugins))
+	for _, p := range externalPlugins {
+		statuses = append(statuses, p.status())
+	}
+	return statuses
+}
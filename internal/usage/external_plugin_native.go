@@ -0,0 +1,35 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package usage
+
+import (
+	"fmt"
+	"plugin"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// nativePluginSymbol is the constructor every Go plugin must export.
+const nativePluginSymbol = "NewUsagePlugin"
+
+// loadNativePlugin opens a Go plugin and returns the plugin built by its
+// NewUsagePlugin function.
+func loadNativePlugin(path string) (coreusage.Plugin, error) {
+	lib, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := lib.Lookup(nativePluginSymbol)
+	if err != nil {
+		return nil, err
+	}
+	constructor, ok := sym.(func() coreusage.Plugin)
+	if !ok {
+		return nil, fmt.Errorf("%s has type %T, want func() usage.Plugin", nativePluginSymbol, sym)
+	}
+	p := constructor()
+	if p == nil {
+		return nil, fmt.Errorf("%s returned a nil plugin", nativePluginSymbol)
+	}
+	return p, nil
+}
@@ -0,0 +1,15 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package usage
+
+import (
+	"errors"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// loadNativePlugin is unavailable in this build: Go plugins require cgo on
+// Linux, macOS or FreeBSD. WASM plugins work in every build.
+func loadNativePlugin(string) (coreusage.Plugin, error) {
+	return nil, errors.New("Go plugins are not supported by this build (requires cgo on linux, darwin or freebsd); ship the plugin as .wasm instead")
+}
@@ -0,0 +1,393 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInfluxMeasurement   = "cliproxy_usage"
+	defaultInfluxBatchSize     = 100
+	defaultInfluxFlushInterval = 10 * time.Second
+	// influxMaxBufferedBatches bounds memory when InfluxDB is unreachable.
+	influxMaxBufferedBatches = 50
+)
+
+var defaultInfluxPlugin = NewInfluxPlugin()
+
+func init() {
+	coreusage.RegisterPlugin(defaultInfluxPlugin)
+}
+
+// InfluxPlugin forwards usage records to InfluxDB v2 as line protocol points.
+// Points are buffered and written in batches by a background goroutine so that
+// the usage dispatcher is never blocked on network I/O.
+type InfluxPlugin struct {
+	mu      sync.Mutex
+	cfg     config.UsageInfluxDB
+	stage   *usageStage
+	pending []string
+	started bool
+	wake    chan struct{}
+	client  *http.Client
+	stats   sinkStats
+}
+
+// NewInfluxPlugin constructs a disabled InfluxDB plugin.
+// Call SetConfig to enable forwarding.
+func NewInfluxPlugin() *InfluxPlugin {
+	return &InfluxPlugin{
+		wake:   make(chan struct{}, 1),
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// SetInfluxDBConfig updates the InfluxDB settings of the shared plugin.
+func SetInfluxDBConfig(cfg config.UsageInfluxDB) { defaultInfluxPlugin.SetConfig(cfg) }
+
+// SetConfig replaces the plugin settings and starts the writer when enabled.
+func (p *InfluxPlugin) SetConfig(cfg config.UsageInfluxDB) {
+	if p == nil {
+		return
+	}
+	cfg.URL = strings.TrimRight(strings.TrimSpace(cfg.URL), "/")
+	if cfg.Enable && (cfg.URL == "" || cfg.Bucket == "") {
+		log.Warn("usage-influxdb: url and bucket are required, forwarding disabled")
+		cfg.Enable = false
+	}
+	cfg.Compression = normaliseCompression("usage-influxdb", cfg.Compression)
+	if cfg.Compression == "zstd" {
+		// The InfluxDB v2 write API only decodes gzip request bodies.
+		log.Warn("usage-influxdb: zstd is not supported by InfluxDB, using gzip")
+		cfg.Compression = "gzip"
+	}
+
+	stage := newUsageStage("usage-influxdb", cfg.Pipeline)
+
+	p.mu.Lock()
+	p.cfg = cfg
+	p.stage = stage
+	if !cfg.Enable {
+		p.pending = nil
+	}
+	startWriter := cfg.Enable && !p.started
+	if startWriter {
+		p.started = true
+	}
+	p.mu.Unlock()
+
+	if startWriter {
+		go p.run()
+	}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *InfluxPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.cfg.Enable {
+		p.mu.Unlock()
+		return
+	}
+	record, labels, ok := p.stage.apply(record)
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	measurement := p.cfg.Measurement
+	if measurement == "" {
+		measurement = defaultInfluxMeasurement
+	}
+	batchSize := influxBatchSize(p.cfg)
+	p.stats.recordHandled()
+	p.pending = append(p.pending, influxLine(measurement, record, labels))
+	if limit := batchSize * influxMaxBufferedBatches; len(p.pending) > limit {
+		dropped := len(p.pending) - limit
+		p.pending = p.pending[dropped:]
+		p.stats.recordDropped(dropped)
+		log.Warnf("usage-influxdb: buffer full, dropped %d oldest points", dropped)
+	}
+	full := len(p.pending) >= batchSize
+	p.mu.Unlock()
+
+	if full {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close writes the buffered points, giving up when ctx is done.
+func (p *InfluxPlugin) Close(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return runUntilDone(ctx, func() error {
+		p.flush()
+		return nil
+	})
+}
+
+func (p *InfluxPlugin) run() {
+	for {
+		p.mu.Lock()
+		interval := time.Duration(p.cfg.FlushInterval) * time.Second
+		p.mu.Unlock()
+		if interval <= 0 {
+			interval = defaultInfluxFlushInterval
+		}
+		select {
+		case <-p.wake:
+		case <-time.After(interval):
+		}
+		p.flush()
+	}
+}
+
+func (p *InfluxPlugin) flush() {
+	p.mu.Lock()
+	cfg := p.cfg
+	lines := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+	if !cfg.Enable || len(lines) == 0 {
+		return
+	}
+
+	sizes := make([]int, len(lines))
+	for i, line := range lines {
+		sizes[i] = len(line)
+	}
+	for _, chunk := range payloadChunks(sizes, influxBatchSize(cfg), cfg.MaxPayloadBytes) {
+		if err := p.write(cfg, lines[chunk[0]:chunk[1]]); err != nil {
+			p.stats.recordFailure(chunk[1]-chunk[0], err)
+			if influxPointsRejected(err) {
+				// InfluxDB rejected the points themselves; retrying would block the queue.
+				log.Errorf("usage-influxdb: write rejected, dropping %d points: %v", chunk[1]-chunk[0], err)
+				continue
+			}
+			log.Warnf("usage-influxdb: write failed: %v", err)
+			p.requeue(lines[chunk[0]:])
+			return
+		}
+		p.stats.recordFlush(chunk[1] - chunk[0])
+	}
+}
+
+func (p *InfluxPlugin) status() PluginStatus {
+	p.mu.Lock()
+	enabled, buffered := p.cfg.Enable, len(p.pending)
+	p.mu.Unlock()
+	return p.stats.snapshot("influxdb", enabled, buffered)
+}
+
+// requeue puts unsent points back in front of anything buffered meanwhile.
+func (p *InfluxPlugin) requeue(lines []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.cfg.Enable {
+		return
+	}
+	merged := make([]string, 0, len(lines)+len(p.pending))
+	merged = append(merged, lines...)
+	merged = append(merged, p.pending...)
+	if limit := influxBatchSize(p.cfg) * influxMaxBufferedBatches; len(merged) > limit {
+		p.stats.recordDropped(len(merged) - limit)
+		merged = merged[len(merged)-limit:]
+	}
+	p.pending = merged
+}
+
+func (p *InfluxPlugin) write(cfg config.UsageInfluxDB, lines []string) error {
+	query := url.Values{}
+	query.Set("org", cfg.Org)
+	query.Set("bucket", cfg.Bucket)
+	query.Set("precision", "ns")
+	endpoint := cfg.URL + "/api/v2/write?" + query.Encode()
+
+	body, err := compressPayload(cfg.Compression, []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if cfg.Compression != "" {
+		req.Header.Set("Content-Encoding", cfg.Compression)
+	}
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+cfg.Token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("usage-influxdb: close response body error: %v", errClose)
+		}
+	}()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// statusError reports a non-2xx response from a usage sink endpoint.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.msg)
+}
+
+// retryableDeliveryError reports whether a failed delivery may succeed later:
+// transport errors, server errors and rate limiting are retried, while other
+// 4xx responses mean the payload itself was rejected.
+func retryableDeliveryError(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.code >= http.StatusInternalServerError || statusErr.code == http.StatusTooManyRequests
+}
+
+// influxPointsRejected reports whether InfluxDB refused the points themselves,
+// as opposed to an auth, routing or availability problem that an operator can
+// fix while the bounded buffer keeps the points.
+func influxPointsRejected(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.code {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// runUntilDone runs fn in the background and waits for it to return or for ctx
+// to be done, whichever comes first.
+func runUntilDone(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func influxBatchSize(cfg config.UsageInfluxDB) int {
+	if cfg.BatchSize > 0 {
+		return cfg.BatchSize
+	}
+	return defaultInfluxBatchSize
+}
+
+// influxLine renders a usage record as a single line protocol point.
+// Tags, including pipeline labels, are emitted in lexical key order as
+// recommended by InfluxDB.
+func influxLine(measurement string, record coreusage.Record, labels map[string]string) string {
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	tokens := normaliseDetail(record.Detail)
+
+	var b strings.Builder
+	b.WriteString(influxEscape(measurement, ", "))
+	tags := make([][2]string, 0, 3+len(labels))
+	tags = append(tags,
+		[2]string{"api_key", hashAPIKey(record.APIKey)},
+		[2]string{"model", record.Model},
+		[2]string{"provider", record.Provider},
+	)
+	for key, value := range labels {
+		tags = append(tags, [2]string{key, value})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i][0] < tags[j][0] })
+	for _, tag := range tags {
+		writeInfluxTag(&b, influxEscape(tag[0], ",= "), tag[1])
+	}
+
+	b.WriteString(" input_tokens=")
+	b.WriteString(strconv.FormatInt(tokens.InputTokens, 10))
+	b.WriteString("i,output_tokens=")
+	b.WriteString(strconv.FormatInt(tokens.OutputTokens, 10))
+	b.WriteString("i,reasoning_tokens=")
+	b.WriteString(strconv.FormatInt(tokens.ReasoningTokens, 10))
+	b.WriteString("i,cached_tokens=")
+	b.WriteString(strconv.FormatInt(tokens.CachedTokens, 10))
+	b.WriteString("i,total_tokens=")
+	b.WriteString(strconv.FormatInt(tokens.TotalTokens, 10))
+	b.WriteString("i,latency_ms=")
+	b.WriteString(strconv.FormatInt(record.Latency.Milliseconds(), 10))
+	b.WriteString("i,failed=")
+	b.WriteString(strconv.FormatBool(record.Failed))
+
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+	return b.String()
+}
+
+func writeInfluxTag(b *strings.Builder, key, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteByte(',')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(influxEscape(value, ",= "))
+}
+
+func influxEscape(value, special string) string {
+	value = strings.ReplaceAll(value, "\n", " ")
+	if !strings.ContainsAny(value, special) {
+		return value
+	}
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// hashAPIKey returns a short, stable identifier for an API key so that
+// downstream systems can group by key without ever seeing the secret.
+func hashAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,328 @@
+package usage
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultKafkaBatchSize     = 100
+	defaultKafkaFlushInterval = time.Second
+	// kafkaMaxPending bounds the messages waiting for the producer goroutine.
+	kafkaMaxPending = 10000
+	// kafkaWriteTimeout bounds a single hand-off to the writer, which blocks
+	// while it looks up topic metadata from unreachable brokers.
+	kafkaWriteTimeout = 10 * time.Second
+)
+
+var defaultKafkaPlugin = NewKafkaPlugin()
+
+func init() {
+	coreusage.RegisterPlugin(defaultKafkaPlugin)
+	defaultPrometheusCollector.addSource(defaultKafkaPlugin.writePrometheus)
+}
+
+// KafkaPlugin publishes usage records as JSON messages to a Kafka topic.
+// Messages are queued and handed to the producer by a background goroutine so
+// that the usage dispatcher never waits on the brokers; delivery results are
+// counted so that failures surface in the Prometheus output instead of only in
+// the log.
+type KafkaPlugin struct {
+	mu      sync.RWMutex
+	writer  *kafka.Writer
+	stage   *usageStage
+	started bool
+
+	queue    chan kafka.Message
+	flushReq chan chan struct{}
+
+	stats    sinkStats
+	everUsed atomic.Bool
+}
+
+// NewKafkaPlugin constructs a disabled Kafka plugin.
+// Call SetConfig to enable publishing.
+func NewKafkaPlugin() *KafkaPlugin {
+	return &KafkaPlugin{
+		queue:    make(chan kafka.Message, kafkaMaxPending),
+		flushReq: make(chan chan struct{}),
+	}
+}
+
+// SetKafkaConfig updates the Kafka settings of the shared plugin.
+func SetKafkaConfig(cfg config.UsageKafka) { defaultKafkaPlugin.SetConfig(cfg) }
+
+// SetConfig replaces the producer. The previous producer is closed in the
+// background so that its buffered messages are still flushed.
+func (p *KafkaPlugin) SetConfig(cfg config.UsageKafka) {
+	if p == nil {
+		return
+	}
+	var writer *kafka.Writer
+	if cfg.Enable {
+		var err error
+		writer, err = p.newWriter(cfg)
+		if err != nil {
+			log.Warnf("usage-kafka: %v, publishing disabled", err)
+			writer = nil
+		}
+	}
+
+	stage := newUsageStage("usage-kafka", cfg.Pipeline)
+
+	p.mu.Lock()
+	previous := p.writer
+	p.writer = writer
+	p.stage = stage
+	startProducer := writer != nil && !p.started
+	if startProducer {
+		p.started = true
+	}
+	p.mu.Unlock()
+
+	if writer != nil {
+		p.everUsed.Store(true)
+	}
+	if startProducer {
+		go p.run()
+	}
+	if previous != nil {
+		go func() {
+			if errClose := previous.Close(); errClose != nil {
+				log.Warnf("usage-kafka: close previous producer error: %v", errClose)
+			}
+		}()
+	}
+}
+
+func (p *KafkaPlugin) newWriter(cfg config.UsageKafka) (*kafka.Writer, error) {
+	brokers := make([]string, 0, len(cfg.Brokers))
+	for _, broker := range cfg.Brokers {
+		if trimmed := strings.TrimSpace(broker); trimmed != "" {
+			brokers = append(brokers, trimmed)
+		}
+	}
+	topic := strings.TrimSpace(cfg.Topic)
+	if len(brokers) == 0 || topic == "" {
+		return nil, fmt.Errorf("brokers and topic are required")
+	}
+
+	transport := &kafka.Transport{}
+	mechanism, err := kafkaSASLMechanism(cfg.SASL)
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL = mechanism
+	if cfg.TLS.Enable {
+		transport.TLS = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		}
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultKafkaBatchSize
+	}
+	batchTimeout := time.Duration(cfg.FlushInterval) * time.Second
+	if batchTimeout <= 0 {
+		batchTimeout = defaultKafkaFlushInterval
+	}
+
+	compression, err := kafkaCompression(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    batchSize,
+		BatchBytes:   int64(max(cfg.MaxPayloadBytes, 0)),
+		BatchTimeout: batchTimeout,
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Compression:  compression,
+		Transport:    transport,
+		Completion:   p.onCompletion,
+	}, nil
+}
+
+func kafkaCompression(value string) (kafka.Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unsupported compression %q", value)
+	}
+}
+
+func kafkaSASLMechanism(cfg config.UsageKafkaSASL) (sasl.Mechanism, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Mechanism)) {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported sasl mechanism %q", cfg.Mechanism)
+	}
+}
+
+func (p *KafkaPlugin) onCompletion(messages []kafka.Message, err error) {
+	if err != nil {
+		p.stats.recordFailure(len(messages), err)
+		log.Warnf("usage-kafka: failed to deliver %d messages: %v", len(messages), err)
+		return
+	}
+	p.stats.recordFlush(len(messages))
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *KafkaPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if p == nil {
+		return
+	}
+	p.mu.RLock()
+	enabled, stage := p.writer != nil, p.stage
+	p.mu.RUnlock()
+	if !enabled {
+		return
+	}
+	record, labels, ok := stage.apply(record)
+	if !ok {
+		return
+	}
+	p.stats.recordHandled()
+	payload := newUsagePayload(record, labels)
+	value, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("usage-kafka: failed to encode record: %v", err)
+		return
+	}
+	msg := kafka.Message{Value: value, Time: payload.Timestamp}
+	if payload.APIKeyHash != "" {
+		msg.Key = []byte(payload.APIKeyHash)
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.stats.recordDropped(1)
+		log.Warn("usage-kafka: queue full, dropping message")
+	}
+}
+
+func (p *KafkaPlugin) run() {
+	for {
+		select {
+		case msg := <-p.queue:
+			p.write(msg)
+		case done := <-p.flushReq:
+			for drained := false; !drained; {
+				select {
+				case msg := <-p.queue:
+					p.write(msg)
+				default:
+					drained = true
+				}
+			}
+			close(done)
+		}
+	}
+}
+
+// write hands one message to the current producer, which batches and sends it
+// in the background and reports the outcome through onCompletion.
+func (p *KafkaPlugin) write(msg kafka.Message) {
+	p.mu.RLock()
+	writer := p.writer
+	p.mu.RUnlock()
+	if writer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancel()
+	if err := writer.WriteMessages(ctx, msg); err != nil {
+		p.stats.recordFailure(1, err)
+		log.Warnf("usage-kafka: failed to enqueue message: %v", err)
+	}
+}
+
+// Close hands the queued messages to the producer, then stops it and waits for
+// its buffered messages to be sent, giving up when ctx is done.
+func (p *KafkaPlugin) Close(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	started := p.started
+	p.mu.RUnlock()
+	if started {
+		done := make(chan struct{})
+		select {
+		case p.flushReq <- done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	writer := p.writer
+	p.writer = nil
+	p.mu.Unlock()
+	if writer == nil {
+		return nil
+	}
+	return runUntilDone(ctx, writer.Close)
+}
+
+// writePrometheus appends the producer delivery counters once Kafka has been enabled.
+func (p *KafkaPlugin) writePrometheus(cw *countingWriter) {
+	if p == nil || !p.everUsed.Load() {
+		return
+	}
+	cw.printf("# HELP cliproxy_usage_kafka_messages_total Usage messages handed to Kafka, by delivery result.\n")
+	cw.printf("# TYPE cliproxy_usage_kafka_messages_total counter\n")
+	cw.printf("cliproxy_usage_kafka_messages_total{result=\"delivered\"} %d\n", p.stats.flushed.Load())
+	cw.printf("cliproxy_usage_kafka_messages_total{result=\"failed\"} %d\n", p.stats.failed.Load())
+}
+
+// status reports the producer counters. Buffered counts the messages not yet
+// handed to the writer; batches inside the writer are not tracked here.
+func (p *KafkaPlugin) status() PluginStatus {
+	p.mu.RLock()
+	enabled := p.writer != nil
+	p.mu.RUnlock()
+	return p.stats.snapshot("kafka", enabled, len(p.queue))
+}
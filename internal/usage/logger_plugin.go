@@ -59,10 +59,12 @@ func StatisticsEnabled() bool { return statisticsEnabled.Load() }
 type RequestStatistics struct {
 	mu sync.RWMutex
 
-	totalRequests int64
-	successCount  int64
-	failureCount  int64
-	totalTokens   int64
+	totalRequests   int64
+	successCount    int64
+	failureCount    int64
+	totalTokens     int64
+	reasoningTokens int64
+	cachedTokens    int64
 
 	apis map[string]*apiStats
 
@@ -74,16 +76,24 @@ type RequestStatistics struct {
 
 // apiStats holds aggregated metrics for a single API key.
 type apiStats struct {
-	TotalRequests int64
-	TotalTokens   int64
-	Models        map[string]*modelStats
+	TotalRequests   int64
+	TotalTokens     int64
+	ReasoningTokens int64
+	CachedTokens    int64
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	Models          map[string]*modelStats
 }
 
 // modelStats holds aggregated metrics for a specific model within an API.
 type modelStats struct {
-	TotalRequests int64
-	TotalTokens   int64
-	Details       []RequestDetail
+	TotalRequests   int64
+	TotalTokens     int64
+	ReasoningTokens int64
+	CachedTokens    int64
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	Details         []RequestDetail
 }
 
 // RequestDetail stores the timestamp and token usage for a single request.
@@ -109,6 +119,10 @@ type StatisticsSnapshot struct {
 	SuccessCount  int64 `json:"success_count"`
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
+	// ReasoningTokens and CachedTokens are the parts of the input and output
+	// totals spent on reasoning and served from the provider cache.
+	ReasoningTokens int64 `json:"reasoning_tokens"`
+	CachedTokens    int64 `json:"cached_tokens"`
 
 	APIs map[string]APISnapshot `json:"apis"`
 
@@ -118,18 +132,27 @@ type StatisticsSnapshot struct {
 	TokensByHour   map[string]int64 `json:"tokens_by_hour"`
 }
 
-// APISnapshot summarises metrics for a single API key.
+// APISnapshot summarises metrics for a single API key. FirstSeen and LastSeen
+// are the earliest and latest request timestamps recorded for it.
 type APISnapshot struct {
-	TotalRequests int64                    `json:"total_requests"`
-	TotalTokens   int64                    `json:"total_tokens"`
-	Models        map[string]ModelSnapshot `json:"models"`
+	TotalRequests   int64                    `json:"total_requests"`
+	TotalTokens     int64                    `json:"total_tokens"`
+	ReasoningTokens int64                    `json:"reasoning_tokens"`
+	CachedTokens    int64                    `json:"cached_tokens"`
+	FirstSeen       time.Time                `json:"first_seen"`
+	LastSeen        time.Time                `json:"last_seen"`
+	Models          map[string]ModelSnapshot `json:"models"`
 }
 
 // ModelSnapshot summarises metrics for a specific model.
 type ModelSnapshot struct {
-	TotalRequests int64           `json:"total_requests"`
-	TotalTokens   int64           `json:"total_tokens"`
-	Details       []RequestDetail `json:"details"`
+	TotalRequests   int64           `json:"total_requests"`
+	TotalTokens     int64           `json:"total_tokens"`
+	ReasoningTokens int64           `json:"reasoning_tokens"`
+	CachedTokens    int64           `json:"cached_tokens"`
+	FirstSeen       time.Time       `json:"first_seen"`
+	LastSeen        time.Time       `json:"last_seen"`
+	Details         []RequestDetail `json:"details"`
 }
 
 var defaultRequestStatistics = NewRequestStatistics()
@@ -188,6 +211,8 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		s.failureCount++
 	}
 	s.totalTokens += totalTokens
+	s.reasoningTokens += detail.ReasoningTokens
+	s.cachedTokens += detail.CachedTokens
 
 	stats, ok := s.apis[statsKey]
 	if !ok {
@@ -210,6 +235,9 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
 	stats.TotalRequests++
 	stats.TotalTokens += detail.Tokens.TotalTokens
+	stats.ReasoningTokens += detail.Tokens.ReasoningTokens
+	stats.CachedTokens += detail.Tokens.CachedTokens
+	stats.FirstSeen, stats.LastSeen = widenSeen(stats.FirstSeen, stats.LastSeen, detail.Timestamp)
 	modelStatsValue, ok := stats.Models[model]
 	if !ok {
 		modelStatsValue = &modelStats{}
@@ -217,9 +245,24 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	}
 	modelStatsValue.TotalRequests++
 	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
+	modelStatsValue.ReasoningTokens += detail.Tokens.ReasoningTokens
+	modelStatsValue.CachedTokens += detail.Tokens.CachedTokens
+	modelStatsValue.FirstSeen, modelStatsValue.LastSeen = widenSeen(modelStatsValue.FirstSeen, modelStatsValue.LastSeen, detail.Timestamp)
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
+// widenSeen extends the first/last seen range to include ts. Records can be
+// published out of order, so both ends are compared.
+func widenSeen(first, last, ts time.Time) (time.Time, time.Time) {
+	if first.IsZero() || ts.Before(first) {
+		first = ts
+	}
+	if ts.After(last) {
+		last = ts
+	}
+	return first, last
+}
+
 // Snapshot returns a copy of the aggregated metrics for external consumption.
 func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result := StatisticsSnapshot{}
@@ -234,24 +277,12 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.ReasoningTokens = s.reasoningTokens
+	result.CachedTokens = s.cachedTokens
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
-		apiSnapshot := APISnapshot{
-			TotalRequests: stats.TotalRequests,
-			TotalTokens:   stats.TotalTokens,
-			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
-		}
-		for modelName, modelStatsValue := range stats.Models {
-			requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
-			copy(requestDetails, modelStatsValue.Details)
-			apiSnapshot.Models[modelName] = ModelSnapshot{
-				TotalRequests: modelStatsValue.TotalRequests,
-				TotalTokens:   modelStatsValue.TotalTokens,
-				Details:       requestDetails,
-			}
-		}
-		result.APIs[apiName] = apiSnapshot
+		result.APIs[apiName] = snapshotAPI(stats)
 	}
 
 	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
@@ -279,6 +310,50 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	return result
 }
 
+// APISnapshot returns a copy of the metrics recorded for a single API key.
+// The boolean reports whether any request has been recorded for the key.
+func (s *RequestStatistics) APISnapshot(apiKey string) (APISnapshot, bool) {
+	if s == nil {
+		return APISnapshot{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats, ok := s.apis[apiKey]
+	if !ok {
+		return APISnapshot{}, false
+	}
+	return snapshotAPI(stats), true
+}
+
+// snapshotAPI copies the metrics of a single API. The caller must hold the statistics lock.
+func snapshotAPI(stats *apiStats) APISnapshot {
+	result := APISnapshot{
+		TotalRequests:   stats.TotalRequests,
+		TotalTokens:     stats.TotalTokens,
+		ReasoningTokens: stats.ReasoningTokens,
+		CachedTokens:    stats.CachedTokens,
+		FirstSeen:       stats.FirstSeen,
+		LastSeen:        stats.LastSeen,
+		Models:          make(map[string]ModelSnapshot, len(stats.Models)),
+	}
+	for modelName, modelStatsValue := range stats.Models {
+		requestDetails := make([]RequestDetail, len(modelStatsValue.Details))
+		copy(requestDetails, modelStatsValue.Details)
+		result.Models[modelName] = ModelSnapshot{
+			TotalRequests:   modelStatsValue.TotalRequests,
+			TotalTokens:     modelStatsValue.TotalTokens,
+			ReasoningTokens: modelStatsValue.ReasoningTokens,
+			CachedTokens:    modelStatsValue.CachedTokens,
+			FirstSeen:       modelStatsValue.FirstSeen,
+			LastSeen:        modelStatsValue.LastSeen,
+			Details:         requestDetails,
+		}
+	}
+	return result
+}
+
 func resolveAPIIdentifier(ctx context.Context, record coreusage.Record) string {
 	if ctx != nil {
 		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
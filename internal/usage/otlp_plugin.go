@@ -0,0 +1,248 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	defaultOTLPExportInterval = 60 * time.Second
+	defaultOTLPServiceName    = "cli-proxy-api"
+	otlpMeterName             = "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	otlpShutdownTimeout       = 10 * time.Second
+)
+
+var defaultOTLPPlugin = NewOTLPPlugin()
+
+func init() {
+	coreusage.RegisterPlugin(defaultOTLPPlugin)
+}
+
+// otlpInstruments groups the meter provider with the instruments recorded on it.
+type otlpInstruments struct {
+	provider *sdkmetric.MeterProvider
+	requests metric.Int64Counter
+	tokens   metric.Int64Counter
+	latency  metric.Float64Histogram
+}
+
+// OTLPPlugin converts usage records into OpenTelemetry counters and a report
+// latency histogram, exported periodically to an OTLP/HTTP collector.
+type OTLPPlugin struct {
+	mu          sync.RWMutex
+	instruments *otlpInstruments
+	stage       *usageStage
+	stats       sinkStats
+}
+
+// NewOTLPPlugin constructs a disabled OTLP plugin.
+// Call SetConfig to enable exporting.
+func NewOTLPPlugin() *OTLPPlugin { return &OTLPPlugin{} }
+
+// SetOTLPConfig updates the OTLP settings of the shared plugin.
+func SetOTLPConfig(cfg config.UsageOTLP) { defaultOTLPPlugin.SetConfig(cfg) }
+
+// SetConfig replaces the exporter. The previous meter provider is shut down in
+// the background, which performs a final export of its pending data.
+func (p *OTLPPlugin) SetConfig(cfg config.UsageOTLP) {
+	if p == nil {
+		return
+	}
+	var instruments *otlpInstruments
+	if cfg.Enable {
+		var err error
+		instruments, err = newOTLPInstruments(cfg, &p.stats)
+		if err != nil {
+			log.Warnf("usage-otlp: %v, exporter disabled", err)
+			instruments = nil
+		}
+	}
+
+	stage := newUsageStage("usage-otlp", cfg.Pipeline)
+
+	p.mu.Lock()
+	previous := p.instruments
+	p.instruments = instruments
+	p.stage = stage
+	p.mu.Unlock()
+
+	if previous != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), otlpShutdownTimeout)
+			defer cancel()
+			if errShutdown := previous.provider.Shutdown(ctx); errShutdown != nil {
+				log.Warnf("usage-otlp: shutdown previous exporter error: %v", errShutdown)
+			}
+		}()
+	}
+}
+
+func newOTLPInstruments(cfg config.UsageOTLP, stats *sinkStats) (*otlpInstruments, error) {
+	endpoint := strings.TrimSpace(cfg.Endpoint)
+	if endpoint == "" {
+		return nil, fmt.Errorf("endpoint is required")
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	if parsed.Path == "" || parsed.Path == "/" {
+		parsed.Path = "/v1/metrics"
+	}
+
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpointURL(parsed.String())}
+	if len(cfg.Headers) > 0 {
+		options = append(options, otlpmetrichttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlpmetrichttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("create exporter: %w", err)
+	}
+
+	interval := time.Duration(cfg.ExportInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultOTLPExportInterval
+	}
+	serviceName := strings.TrimSpace(cfg.ServiceName)
+	if serviceName == "" {
+		serviceName = defaultOTLPServiceName
+	}
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(&otlpCountingExporter{Exporter: exporter, stats: stats}, sdkmetric.WithInterval(interval))),
+	)
+
+	instruments, err := registerOTLPInstruments(provider)
+	if err != nil {
+		_ = provider.Shutdown(context.Background())
+		return nil, err
+	}
+	return instruments, nil
+}
+
+// otlpCountingExporter records the outcome of every export in the plugin stats.
+type otlpCountingExporter struct {
+	sdkmetric.Exporter
+	stats *sinkStats
+}
+
+func (e *otlpCountingExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	if err != nil {
+		e.stats.recordFailure(1, err)
+	} else {
+		e.stats.recordFlush(1)
+	}
+	return err
+}
+
+func registerOTLPInstruments(provider *sdkmetric.MeterProvider) (*otlpInstruments, error) {
+	meter := provider.Meter(otlpMeterName)
+	instruments := &otlpInstruments{provider: provider}
+	var err error
+	if instruments.requests, err = meter.Int64Counter("cliproxy.usage.requests",
+		metric.WithDescription("Upstream requests recorded by the usage pipeline."),
+		metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+	if instruments.tokens, err = meter.Int64Counter("cliproxy.usage.tokens",
+		metric.WithDescription("Tokens consumed, partitioned by token type."),
+		metric.WithUnit("{token}")); err != nil {
+		return nil, err
+	}
+	if instruments.latency, err = meter.Float64Histogram("cliproxy.usage.report.latency",
+		metric.WithDescription("Time from request start until its usage was reported; for streams this is the first chunk carrying usage."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(promLatencyBounds...)); err != nil {
+		return nil, err
+	}
+	return instruments, nil
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *OTLPPlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if p == nil {
+		return
+	}
+	p.mu.RLock()
+	instruments := p.instruments
+	stage := p.stage
+	p.mu.RUnlock()
+	if instruments == nil {
+		return
+	}
+	record, labels, ok := stage.apply(record)
+	if !ok {
+		return
+	}
+	p.stats.recordHandled()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Recording must not be cut short by the originating request being done.
+	ctx = context.WithoutCancel(ctx)
+
+	base := []attribute.KeyValue{
+		attribute.String("provider", record.Provider),
+		attribute.String("model", record.Model),
+	}
+	for key, value := range labels {
+		base = append(base, attribute.String(key, value))
+	}
+	instruments.requests.Add(ctx, 1, metric.WithAttributes(append(base, attribute.Bool("failed", record.Failed))...))
+
+	tokens := normaliseDetail(record.Detail)
+	for _, item := range []struct {
+		kind  string
+		value int64
+	}{
+		{"input", tokens.InputTokens},
+		{"output", tokens.OutputTokens},
+		{"reasoning", tokens.ReasoningTokens},
+		{"cached", tokens.CachedTokens},
+	} {
+		if item.value > 0 {
+			instruments.tokens.Add(ctx, item.value, metric.WithAttributes(append(base, attribute.String("type", item.kind))...))
+		}
+	}
+
+	if record.Latency > 0 {
+		instruments.latency.Record(ctx, record.Latency.Seconds(), metric.WithAttributes(base...))
+	}
+}
+
+// Close exports the last collected metrics and shuts the exporter down.
+func (p *OTLPPlugin) Close(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	instruments := p.instruments
+	p.instruments = nil
+	p.mu.Unlock()
+	if instruments == nil {
+		return nil
+	}
+	return instruments.provider.Shutdown(ctx)
+}
+
+func (p *OTLPPlugin) status() PluginStatus {
+	p.mu.RLock()
+	enabled := p.instruments != nil
+	p.mu.RUnlock()
+	return p.stats.snapshot("otlp", enabled, 0)
+}
@@ -0,0 +1,177 @@
+package usage
+
+import (
+	"math/rand/v2"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// reservedLabelKeys are the tag and attribute names the sinks set themselves.
+// A static label with one of these names would duplicate an InfluxDB tag, which
+// rejects the point, or overwrite an OTLP attribute.
+var reservedLabelKeys = map[string]struct{}{
+	"api_key":  {},
+	"failed":   {},
+	"model":    {},
+	"provider": {},
+	"type":     {},
+}
+
+// usageStage is the compiled form of a config.UsagePipeline. A nil stage passes
+// every record through unchanged.
+type usageStage struct {
+	providers  map[string]struct{}
+	models     []string
+	apiKeys    map[string]struct{}
+	sampleRate float64
+
+	redactAPIKey bool
+	redactAuthID bool
+	redactSource bool
+	redactModel  bool
+
+	labels map[string]string
+}
+
+// newUsageStage compiles the pipeline settings of the named sink. It returns nil
+// when the settings neither filter nor transform anything.
+func newUsageStage(sink string, cfg config.UsagePipeline) *usageStage {
+	stage := &usageStage{}
+	empty := true
+	for _, provider := range cfg.Providers {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			if stage.providers == nil {
+				stage.providers = make(map[string]struct{})
+			}
+			stage.providers[provider] = struct{}{}
+			empty = false
+		}
+	}
+	for _, model := range cfg.Models {
+		if model = strings.ToLower(strings.TrimSpace(model)); model != "" {
+			stage.models = append(stage.models, model)
+			empty = false
+		}
+	}
+	for _, key := range cfg.APIKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			if stage.apiKeys == nil {
+				stage.apiKeys = make(map[string]struct{})
+			}
+			stage.apiKeys[key] = struct{}{}
+			empty = false
+		}
+	}
+	if cfg.SampleRate > 0 && cfg.SampleRate < 1 {
+		stage.sampleRate = cfg.SampleRate
+		empty = false
+	} else if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		log.Warnf("%s: sample-rate %v is outside 0-1, forwarding every record", sink, cfg.SampleRate)
+	}
+	for _, field := range cfg.Redact {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "api-key":
+			stage.redactAPIKey = true
+		case "auth-id":
+			stage.redactAuthID = true
+		case "source":
+			stage.redactSource = true
+		case "model":
+			stage.redactModel = true
+		default:
+			log.Warnf("%s: unknown redact field %q ignored", sink, field)
+			continue
+		}
+		empty = false
+	}
+	for key, value := range cfg.Labels {
+		if key = strings.TrimSpace(key); key != "" {
+			if _, reserved := reservedLabelKeys[strings.ToLower(key)]; reserved {
+				log.Warnf("%s: label %q clashes with a built-in tag and is ignored", sink, key)
+				continue
+			}
+			if stage.labels == nil {
+				stage.labels = make(map[string]string)
+			}
+			stage.labels[key] = value
+			empty = false
+		}
+	}
+	if empty {
+		return nil
+	}
+	return stage
+}
+
+// apply runs the record through the stage. It returns the transformed record,
+// the static labels to attach, and false when the record must be skipped.
+func (s *usageStage) apply(record coreusage.Record) (coreusage.Record, map[string]string, bool) {
+	if s == nil {
+		return record, nil, true
+	}
+	if s.providers != nil {
+		if _, ok := s.providers[strings.ToLower(record.Provider)]; !ok {
+			return record, nil, false
+		}
+	}
+	if len(s.models) > 0 {
+		model := strings.ToLower(record.Model)
+		matched := false
+		for _, pattern := range s.models {
+			if matchWildcard(pattern, model) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return record, nil, false
+		}
+	}
+	if s.apiKeys != nil {
+		if _, ok := s.apiKeys[record.APIKey]; !ok {
+			return record, nil, false
+		}
+	}
+	if s.sampleRate > 0 && rand.Float64() >= s.sampleRate {
+		return record, nil, false
+	}
+
+	if s.redactAPIKey {
+		record.APIKey = ""
+	}
+	if s.redactAuthID {
+		record.AuthID = ""
+	}
+	if s.redactSource {
+		record.Source = ""
+	}
+	if s.redactModel {
+		record.Model = ""
+	}
+	return record, s.labels, true
+}
+
+// matchWildcard reports whether value matches pattern, where "*" matches any
+// run of characters, including "/".
+func matchWildcard(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, part)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(part):]
+	}
+	return len(value) >= len(last) && strings.HasSuffix(value, last)
+}
@@ -0,0 +1,100 @@
+package usage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PluginStatus is a point-in-time view of a usage sink's runtime counters.
+type PluginStatus struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Handled counts records accepted by the sink after its pipeline filters.
+	Handled int64 `json:"handled"`
+	// Buffered is the number of records waiting to be sent.
+	Buffered int `json:"buffered"`
+	// Flushed counts records, or exports for OTLP, that reached the destination.
+	Flushed int64 `json:"flushed"`
+	// Failed counts records, or exports for OTLP, whose delivery attempt failed.
+	// Buffered sinks retry them, so a failure is not necessarily a loss.
+	Failed int64 `json:"failed"`
+	// Dropped counts records discarded because a buffer was full.
+	Dropped     int64      `json:"dropped"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	LastFlushAt *time.Time `json:"last_flush_at,omitempty"`
+}
+
+// PluginStatuses reports the runtime counters of every built-in usage sink,
+// followed by the loaded WASM plugins.
+func PluginStatuses() []PluginStatus {
+	statuses := []PluginStatus{
+		defaultInfluxPlugin.status(),
+		defaultWebhookPlugin.status(),
+		defaultKafkaPlugin.status(),
+		defaultOTLPPlugin.status(),
+		defaultAuditLogPlugin.status(),
+	}
+	return append(statuses, externalStatuses()...)
+}
+
+// sinkStats holds the counters shared by the usage sinks. It is safe for
+// concurrent use and independent of the sink's own locking.
+type sinkStats struct {
+	handled atomic.Int64
+	flushed atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+	lastFlushAt time.Time
+}
+
+func (s *sinkStats) recordHandled() { s.handled.Add(1) }
+
+func (s *sinkStats) recordDropped(n int) { s.dropped.Add(int64(n)) }
+
+func (s *sinkStats) recordFlush(n int) {
+	s.flushed.Add(int64(n))
+	s.mu.Lock()
+	s.lastFlushAt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *sinkStats) recordFailure(n int, err error) {
+	s.failed.Add(int64(n))
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *sinkStats) snapshot(name string, enabled bool, buffered int) PluginStatus {
+	status := PluginStatus{
+		Name:     name,
+		Enabled:  enabled,
+		Handled:  s.handled.Load(),
+		Buffered: buffered,
+		Flushed:  s.flushed.Load(),
+		Failed:   s.failed.Load(),
+		Dropped:  s.dropped.Load(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.LastError = s.lastError
+	if !s.lastErrorAt.IsZero() {
+		at := s.lastErrorAt
+		status.LastErrorAt = &at
+	}
+	if !s.lastFlushAt.IsZero() {
+		at := s.lastFlushAt
+		status.LastFlushAt = &at
+	}
+	return status
+}
@@ -0,0 +1,204 @@
+package usage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// promLatencyBounds are the upper bounds, in seconds, of the usage report latency histogram.
+var promLatencyBounds = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var defaultPrometheusCollector = NewPrometheusCollector()
+
+func init() {
+	coreusage.RegisterPlugin(defaultPrometheusCollector)
+}
+
+// GetPrometheusCollector returns the shared Prometheus collector.
+func GetPrometheusCollector() *PrometheusCollector { return defaultPrometheusCollector }
+
+// PrometheusCollector keeps cumulative per provider/model counters that are
+// rendered in the Prometheus text exposition format. It is fed directly by the
+// usage dispatcher, so scrapes never walk the per-request details.
+type PrometheusCollector struct {
+	mu      sync.Mutex
+	series  map[promSeriesKey]*promSeries
+	sources []func(*countingWriter)
+}
+
+type promSeriesKey struct {
+	provider string
+	model    string
+}
+
+type promSeries struct {
+	requests        int64
+	failures        int64
+	inputTokens     int64
+	outputTokens    int64
+	reasoningTokens int64
+	cachedTokens    int64
+	latencyBuckets  []int64
+	latencyCount    int64
+	latencySum      float64
+}
+
+// NewPrometheusCollector constructs an empty collector.
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{series: make(map[promSeriesKey]*promSeries)}
+}
+
+// addSource registers an extra writer whose metrics are rendered after the usage series.
+func (c *PrometheusCollector) addSource(source func(*countingWriter)) {
+	if c == nil || source == nil {
+		return
+	}
+	c.mu.Lock()
+	c.sources = append(c.sources, source)
+	c.mu.Unlock()
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (c *PrometheusCollector) HandleUsage(_ context.Context, record coreusage.Record) {
+	if c == nil {
+		return
+	}
+	key := promSeriesKey{provider: record.Provider, model: record.Model}
+	if key.provider == "" {
+		key.provider = "unknown"
+	}
+	if key.model == "" {
+		key.model = "unknown"
+	}
+	tokens := normaliseDetail(record.Detail)
+	seconds := record.Latency.Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &promSeries{latencyBuckets: make([]int64, len(promLatencyBounds))}
+		c.series[key] = s
+	}
+	s.requests++
+	if record.Failed {
+		s.failures++
+	}
+	s.inputTokens += tokens.InputTokens
+	s.outputTokens += tokens.OutputTokens
+	s.reasoningTokens += tokens.ReasoningTokens
+	s.cachedTokens += tokens.CachedTokens
+	if record.Latency > 0 {
+		for i, bound := range promLatencyBounds {
+			if seconds <= bound {
+				s.latencyBuckets[i]++
+			}
+		}
+		s.latencyCount++
+		s.latencySum += seconds
+	}
+}
+
+// WriteTo renders all series in the Prometheus text exposition format (version 0.0.4).
+func (c *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	type entry struct {
+		key    promSeriesKey
+		series promSeries
+	}
+	var (
+		entries []entry
+		sources []func(*countingWriter)
+	)
+	if c != nil {
+		c.mu.Lock()
+		entries = make([]entry, 0, len(c.series))
+		for key, s := range c.series {
+			copied := *s
+			copied.latencyBuckets = append([]int64(nil), s.latencyBuckets...)
+			entries = append(entries, entry{key: key, series: copied})
+		}
+		sources = append(sources, c.sources...)
+		c.mu.Unlock()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key.provider != entries[j].key.provider {
+			return entries[i].key.provider < entries[j].key.provider
+		}
+		return entries[i].key.model < entries[j].key.model
+	})
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	labels := func(key promSeriesKey) string {
+		return fmt.Sprintf(`provider="%s",model="%s"`, promEscape(key.provider), promEscape(key.model))
+	}
+
+	cw.printf("# HELP cliproxy_usage_requests_total Total upstream requests recorded by the usage pipeline.\n")
+	cw.printf("# TYPE cliproxy_usage_requests_total counter\n")
+	for _, e := range entries {
+		cw.printf("cliproxy_usage_requests_total{%s} %d\n", labels(e.key), e.series.requests)
+	}
+
+	cw.printf("# HELP cliproxy_usage_failed_requests_total Upstream requests that ended in failure.\n")
+	cw.printf("# TYPE cliproxy_usage_failed_requests_total counter\n")
+	for _, e := range entries {
+		cw.printf("cliproxy_usage_failed_requests_total{%s} %d\n", labels(e.key), e.series.failures)
+	}
+
+	cw.printf("# HELP cliproxy_usage_tokens_total Tokens consumed, partitioned by token type.\n")
+	cw.printf("# TYPE cliproxy_usage_tokens_total counter\n")
+	for _, e := range entries {
+		l := labels(e.key)
+		cw.printf("cliproxy_usage_tokens_total{%s,type=\"input\"} %d\n", l, e.series.inputTokens)
+		cw.printf("cliproxy_usage_tokens_total{%s,type=\"output\"} %d\n", l, e.series.outputTokens)
+		cw.printf("cliproxy_usage_tokens_total{%s,type=\"reasoning\"} %d\n", l, e.series.reasoningTokens)
+		cw.printf("cliproxy_usage_tokens_total{%s,type=\"cached\"} %d\n", l, e.series.cachedTokens)
+	}
+
+	cw.printf("# HELP cliproxy_usage_report_latency_seconds Time from request start until its usage was reported; for streams this is the first chunk carrying usage.\n")
+	cw.printf("# TYPE cliproxy_usage_report_latency_seconds histogram\n")
+	for _, e := range entries {
+		l := labels(e.key)
+		for i, bound := range promLatencyBounds {
+			cw.printf("cliproxy_usage_report_latency_seconds_bucket{%s,le=\"%s\"} %d\n", l, strconv.FormatFloat(bound, 'g', -1, 64), e.series.latencyBuckets[i])
+		}
+		cw.printf("cliproxy_usage_report_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, e.series.latencyCount)
+		cw.printf("cliproxy_usage_report_latency_seconds_sum{%s} %s\n", l, strconv.FormatFloat(e.series.latencySum, 'g', -1, 64))
+		cw.printf("cliproxy_usage_report_latency_seconds_count{%s} %d\n", l, e.series.latencyCount)
+	}
+
+	for _, source := range sources {
+		source(cw)
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...any) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(value string) string { return promLabelEscaper.Replace(value) }
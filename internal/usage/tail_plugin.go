@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+var defaultTailBroadcaster = NewTailBroadcaster()
+
+func init() {
+	coreusage.RegisterPlugin(defaultTailBroadcaster)
+}
+
+// GetTailBroadcaster returns the shared live tail broadcaster.
+func GetTailBroadcaster() *TailBroadcaster { return defaultTailBroadcaster }
+
+// TailEvent is the client-facing view of a completed request streamed to live tail subscribers.
+type TailEvent struct {
+	Timestamp time.Time  `json:"timestamp"`
+	Provider  string     `json:"provider"`
+	Model     string     `json:"model"`
+	APIKey    string     `json:"api_key"`
+	Source    string     `json:"source"`
+	Failed    bool       `json:"failed"`
+	LatencyMS int64      `json:"latency_ms"`
+	Tokens    TokenStats `json:"tokens"`
+}
+
+// TailFilter selects the events delivered to a subscriber. Empty fields match everything.
+type TailFilter struct {
+	Provider   string
+	Model      string
+	APIKey     string
+	FailedOnly bool
+}
+
+func (f TailFilter) matches(record coreusage.Record) bool {
+	if f.Provider != "" && !strings.EqualFold(f.Provider, record.Provider) {
+		return false
+	}
+	if f.Model != "" && !strings.EqualFold(f.Model, record.Model) {
+		return false
+	}
+	if f.APIKey != "" && f.APIKey != record.APIKey {
+		return false
+	}
+	if f.FailedOnly && !record.Failed {
+		return false
+	}
+	return true
+}
+
+type tailSubscription struct {
+	filter TailFilter
+	ch     chan TailEvent
+}
+
+// TailBroadcaster fans usage records out to live subscribers. Slow subscribers
+// lose events rather than stalling the usage dispatcher.
+type TailBroadcaster struct {
+	mu   sync.RWMutex
+	subs map[*tailSubscription]struct{}
+}
+
+// NewTailBroadcaster constructs a broadcaster without subscribers.
+func NewTailBroadcaster() *TailBroadcaster {
+	return &TailBroadcaster{subs: make(map[*tailSubscription]struct{})}
+}
+
+// Subscribe registers a subscriber and returns its event channel together with
+// a cancel function that must be called once the subscriber goes away.
+func (b *TailBroadcaster) Subscribe(filter TailFilter, buffer int) (<-chan TailEvent, func()) {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	sub := &tailSubscription{filter: filter, ch: make(chan TailEvent, buffer)}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (b *TailBroadcaster) HandleUsage(_ context.Context, record coreusage.Record) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.subs) == 0 {
+		return
+	}
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	event := TailEvent{
+		Timestamp: timestamp,
+		Provider:  record.Provider,
+		Model:     record.Model,
+		APIKey:    util.HideAPIKey(record.APIKey),
+		Source:    record.Source,
+		Failed:    record.Failed,
+		LatencyMS: record.Latency.Milliseconds(),
+		Tokens:    normaliseDetail(record.Detail),
+	}
+	for sub := range b.subs {
+		if !sub.filter.matches(record) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WASM plugin ABI, version 1.
+//
+// The module must export its linear memory as "memory" and two functions:
+//
+//	cliproxy_alloc(size i32) -> i32        returns a buffer of size bytes owned by the module
+//	cliproxy_handle_usage(ptr i32, len i32) receives one usage record as JSON in that buffer
+//
+// The record uses the same JSON shape as the webhook sink and never contains
+// the raw API key. A reactor-style "_initialize" export is called once after
+// instantiation. WASI preview1 is available, plus these host functions in the
+// "cliproxy" module:
+//
+//	log(level i32, ptr i32, len i32)  level 0=debug 1=info 2=warn 3=error
+//	http_post(url_ptr i32, url_len i32, body_ptr i32, body_len i32) -> i32
+//	    POSTs a JSON body and returns the HTTP status code, or -1 on error
+const (
+	wasmAllocExport  = "cliproxy_alloc"
+	wasmHandleExport = "cliproxy_handle_usage"
+	wasmHostModule   = "cliproxy"
+
+	// wasmQueueSize bounds the records waiting for a slow plugin.
+	wasmQueueSize = 1024
+	// wasmCallTimeout aborts a plugin call that does not return. The aborted module
+	// is closed and instantiated again, so guest state does not survive it.
+	wasmCallTimeout = 30 * time.Second
+	wasmHTTPTimeout = 15 * time.Second
+)
+
+var wasmRuntime = sync.OnceValues(newWASMRuntime)
+
+func newWASMRuntime() (wazero.Runtime, error) {
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("instantiate wasi: %w", err)
+	}
+	client := &http.Client{Timeout: wasmHTTPTimeout}
+	_, err := runtime.NewHostModuleBuilder(wasmHostModule).
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, level, ptr, size uint32) {
+			msg, ok := m.Memory().Read(ptr, size)
+			if !ok {
+				return
+			}
+			entry := log.WithField("plugin", m.Name())
+			switch level {
+			case 0:
+				entry.Debug(string(msg))
+			case 1:
+				entry.Info(string(msg))
+			case 2:
+				entry.Warn(string(msg))
+			default:
+				entry.Error(string(msg))
+			}
+		}).
+		Export("log").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, urlPtr, urlLen, bodyPtr, bodyLen uint32) int32 {
+			url, okURL := m.Memory().Read(urlPtr, urlLen)
+			body, okBody := m.Memory().Read(bodyPtr, bodyLen)
+			if !okURL || !okBody {
+				return -1
+			}
+			req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, string(url), bytes.NewReader(bytes.Clone(body)))
+			if errReq != nil {
+				return -1
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, errDo := client.Do(req)
+			if errDo != nil {
+				log.WithField("plugin", m.Name()).Debugf("http_post failed: %v", errDo)
+				return -1
+			}
+			_ = resp.Body.Close()
+			return int32(resp.StatusCode)
+		}).
+		Export("http_post").
+		Instantiate(ctx)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("instantiate host module: %w", err)
+	}
+	return runtime, nil
+}
+
+// WASMPlugin runs a usage plugin compiled to WebAssembly. Records are handed to
+// the module from a dedicated goroutine so a slow plugin never blocks the usage
+// dispatcher; when its queue is full, records are dropped and counted. A module
+// closed by a timed out call or by the guest exiting is instantiated again.
+type WASMPlugin struct {
+	name     string
+	compiled wazero.CompiledModule
+	module   api.Module
+	alloc    api.Function
+	handle   api.Function
+	queue    chan []byte
+	disabled atomic.Bool
+	stats    sinkStats
+}
+
+// LoadWASMPlugin compiles and instantiates the module at path and starts its worker.
+func LoadWASMPlugin(path string) (*WASMPlugin, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := wasmRuntime()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("compile: %w", err)
+	}
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		return nil, errors.New(`module does not export "memory"`)
+	}
+	exports := compiled.ExportedFunctions()
+	for _, name := range []string{wasmAllocExport, wasmHandleExport} {
+		if _, ok := exports[name]; !ok {
+			return nil, fmt.Errorf("module does not export %q", name)
+		}
+	}
+
+	p := &WASMPlugin{
+		name:     filepath.Base(path),
+		compiled: compiled,
+		queue:    make(chan []byte, wasmQueueSize),
+	}
+	if err = p.instantiate(runtime); err != nil {
+		return nil, err
+	}
+	go p.run()
+	return p, nil
+}
+
+// instantiate creates a fresh instance of the compiled module.
+func (p *WASMPlugin) instantiate(runtime wazero.Runtime) error {
+	module, err := runtime.InstantiateModule(context.Background(), p.compiled, wazero.NewModuleConfig().
+		WithName(p.name).
+		WithStartFunctions("_initialize").
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader))
+	if err != nil {
+		return fmt.Errorf("instantiate: %w", err)
+	}
+	p.module = module
+	p.alloc = module.ExportedFunction(wasmAllocExport)
+	p.handle = module.ExportedFunction(wasmHandleExport)
+	return nil
+}
+
+// restart replaces a closed module. A plugin that cannot be instantiated again
+// is disabled and stops receiving records.
+func (p *WASMPlugin) restart() {
+	runtime, err := wasmRuntime()
+	if err == nil {
+		err = p.instantiate(runtime)
+	}
+	if err != nil {
+		p.disabled.Store(true)
+		log.Errorf("usage-plugin %s: module closed and could not be restarted, plugin disabled: %v", p.name, err)
+		return
+	}
+	log.Warnf("usage-plugin %s: module closed, instantiated again", p.name)
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *WASMPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if p == nil || p.disabled.Load() {
+		return
+	}
+	body, err := json.Marshal(newUsagePayload(record, nil))
+	if err != nil {
+		log.Errorf("usage-plugin %s: failed to encode record: %v", p.name, err)
+		return
+	}
+	p.stats.recordHandled()
+	select {
+	case p.queue <- body:
+	default:
+		p.stats.recordDropped(1)
+	}
+}
+
+func (p *WASMPlugin) run() {
+	for body := range p.queue {
+		if p.disabled.Load() {
+			p.stats.recordDropped(1)
+			continue
+		}
+		if err := p.call(body); err != nil {
+			log.Warnf("usage-plugin %s: %v", p.name, err)
+			p.stats.recordFailure(1, err)
+			if p.module.IsClosed() {
+				p.restart()
+			}
+			continue
+		}
+		p.stats.recordFlush(1)
+	}
+}
+
+func (p *WASMPlugin) call(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wasmCallTimeout)
+	defer cancel()
+	results, err := p.alloc.Call(ctx, uint64(len(body)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", wasmAllocExport, err)
+	}
+	ptr := uint32(results[0])
+	if !p.module.Memory().Write(ptr, body) {
+		return fmt.Errorf("%s returned an out of range buffer", wasmAllocExport)
+	}
+	if _, err = p.handle.Call(ctx, uint64(ptr), uint64(len(body))); err != nil {
+		return fmt.Errorf("%s: %w", wasmHandleExport, err)
+	}
+	return nil
+}
+
+func (p *WASMPlugin) status() PluginStatus {
+	return p.stats.snapshot("wasm:"+p.name, !p.disabled.Load(), len(p.queue))
+}
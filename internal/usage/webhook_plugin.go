@@ -0,0 +1,389 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookFlushInterval = 5 * time.Second
+	defaultWebhookMaxRetries    = 3
+	webhookRetryBaseDelay       = time.Second
+	// webhookMaxPending bounds memory while the endpoint is unreachable.
+	webhookMaxPending = 10000
+)
+
+var defaultWebhookPlugin = NewWebhookPlugin()
+
+func init() {
+	coreusage.RegisterPlugin(defaultWebhookPlugin)
+}
+
+// usagePayload is the JSON representation of a usage record handed to external systems.
+// The raw API key never leaves the process; consumers get a masked form for display
+// and a stable hash for grouping.
+type usagePayload struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Provider   string            `json:"provider"`
+	Model      string            `json:"model"`
+	APIKey     string            `json:"api_key,omitempty"`
+	APIKeyHash string            `json:"api_key_hash,omitempty"`
+	AuthID     string            `json:"auth_id,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	Failed     bool              `json:"failed"`
+	LatencyMS  int64             `json:"latency_ms"`
+	Tokens     TokenStats        `json:"tokens"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+func newUsagePayload(record coreusage.Record, labels map[string]string) usagePayload {
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return usagePayload{
+		Timestamp:  timestamp,
+		Provider:   record.Provider,
+		Model:      record.Model,
+		APIKey:     util.HideAPIKey(record.APIKey),
+		APIKeyHash: hashAPIKey(record.APIKey),
+		AuthID:     record.AuthID,
+		Source:     record.Source,
+		Failed:     record.Failed,
+		LatencyMS:  record.Latency.Milliseconds(),
+		Tokens:     normaliseDetail(record.Detail),
+		Labels:     labels,
+	}
+}
+
+// WebhookPlugin POSTs usage records to a configured URL, optionally batched and
+// HMAC signed. Deliveries are retried with exponential backoff. Batches that
+// still fail, and records pushed out of a full buffer, are appended to the
+// dead-letter file when one is configured and are otherwise dropped.
+type WebhookPlugin struct {
+	mu      sync.Mutex
+	cfg     config.UsageWebhook
+	stage   *usageStage
+	pending []usagePayload
+	started bool
+	wake    chan struct{}
+	client  *http.Client
+	stats   sinkStats
+}
+
+// NewWebhookPlugin constructs a disabled webhook plugin.
+// Call SetConfig to enable delivery.
+func NewWebhookPlugin() *WebhookPlugin {
+	return &WebhookPlugin{
+		wake:   make(chan struct{}, 1),
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// SetWebhookConfig updates the webhook settings of the shared plugin.
+func SetWebhookConfig(cfg config.UsageWebhook) { defaultWebhookPlugin.SetConfig(cfg) }
+
+// SetConfig replaces the plugin settings and starts the sender when enabled.
+func (p *WebhookPlugin) SetConfig(cfg config.UsageWebhook) {
+	if p == nil {
+		return
+	}
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	cfg.Compression = normaliseCompression("usage-webhook", cfg.Compression)
+	if cfg.Enable && cfg.URL == "" {
+		log.Warn("usage-webhook: url is required, delivery disabled")
+		cfg.Enable = false
+	}
+
+	stage := newUsageStage("usage-webhook", cfg.Pipeline)
+
+	p.mu.Lock()
+	p.cfg = cfg
+	p.stage = stage
+	if !cfg.Enable {
+		p.pending = nil
+	}
+	startSender := cfg.Enable && !p.started
+	if startSender {
+		p.started = true
+	}
+	p.mu.Unlock()
+
+	if startSender {
+		go p.run()
+	}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (p *WebhookPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.cfg.Enable {
+		p.mu.Unlock()
+		return
+	}
+	record, labels, ok := p.stage.apply(record)
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	p.stats.recordHandled()
+	p.pending = append(p.pending, newUsagePayload(record, labels))
+	overflow := p.trimPending()
+	full := len(p.pending) >= webhookBatchSize(p.cfg)
+	deadLetterFile := p.cfg.DeadLetterFile
+	p.mu.Unlock()
+
+	p.deadLetter(deadLetterFile, overflow)
+	if full {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close delivers the buffered records, giving up when ctx is done.
+func (p *WebhookPlugin) Close(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return runUntilDone(ctx, func() error {
+		p.flush()
+		return nil
+	})
+}
+
+// trimPending drops the oldest records beyond webhookMaxPending and returns them.
+// The caller must hold p.mu.
+func (p *WebhookPlugin) trimPending() []usagePayload {
+	if len(p.pending) <= webhookMaxPending {
+		return nil
+	}
+	dropped := len(p.pending) - webhookMaxPending
+	overflow := p.pending[:dropped:dropped]
+	p.pending = p.pending[dropped:]
+	p.stats.recordDropped(dropped)
+	log.Warnf("usage-webhook: buffer full, dropped %d oldest records", dropped)
+	return overflow
+}
+
+// requeue puts records that were not attempted back in front of the buffer.
+func (p *WebhookPlugin) requeue(records []usagePayload) {
+	p.mu.Lock()
+	if !p.cfg.Enable {
+		p.mu.Unlock()
+		return
+	}
+	merged := make([]usagePayload, 0, len(records)+len(p.pending))
+	merged = append(merged, records...)
+	p.pending = append(merged, p.pending...)
+	overflow := p.trimPending()
+	deadLetterFile := p.cfg.DeadLetterFile
+	p.mu.Unlock()
+
+	p.deadLetter(deadLetterFile, overflow)
+}
+
+// deadLetter appends records, one JSON object per line, to the dead-letter file.
+func (p *WebhookPlugin) deadLetter(path string, records []usagePayload) {
+	if path == "" || len(records) == 0 {
+		return
+	}
+	lines := make([][]byte, 0, len(records))
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			log.Errorf("usage-webhook: failed to encode payload: %v", err)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := appendDeadLetter(path, lines...); err != nil {
+		log.Errorf("usage-webhook: failed to write dead-letter file: %v", err)
+	}
+}
+
+func (p *WebhookPlugin) run() {
+	for {
+		p.mu.Lock()
+		interval := time.Duration(p.cfg.FlushInterval) * time.Second
+		p.mu.Unlock()
+		if interval <= 0 {
+			interval = defaultWebhookFlushInterval
+		}
+		select {
+		case <-p.wake:
+		case <-time.After(interval):
+		}
+		p.flush()
+	}
+}
+
+func (p *WebhookPlugin) flush() {
+	p.mu.Lock()
+	cfg := p.cfg
+	records := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+	if !cfg.Enable || len(records) == 0 {
+		return
+	}
+
+	kept := records[:0]
+	encoded := make([][]byte, 0, len(records))
+	sizes := make([]int, 0, len(records))
+	for _, record := range records {
+		item, err := json.Marshal(record)
+		if err != nil {
+			log.Errorf("usage-webhook: failed to encode payload: %v", err)
+			continue
+		}
+		kept = append(kept, record)
+		encoded = append(encoded, item)
+		sizes = append(sizes, len(item))
+	}
+
+	batchSize := webhookBatchSize(cfg)
+	maxBytes := cfg.MaxPayloadBytes
+	if maxBytes > 0 && batchSize > 1 {
+		maxBytes -= 2 // array brackets
+	}
+	for _, chunk := range payloadChunks(sizes, batchSize, maxBytes) {
+		body := encoded[chunk[0]]
+		if batchSize > 1 {
+			body = append(append([]byte{'['}, bytes.Join(encoded[chunk[0]:chunk[1]], []byte{','})...), ']')
+		}
+		if err := p.deliver(cfg, body, chunk[1]-chunk[0]); err != nil && retryableDeliveryError(err) {
+			// The endpoint is down; keep the rest for the next flush instead of
+			// spending the full retry schedule on every remaining batch.
+			p.requeue(kept[chunk[1]:])
+			return
+		}
+	}
+}
+
+// deliver sends one payload, retrying transient failures with exponential
+// backoff before falling back to the dead-letter file.
+// deliver posts one batch with retries. It returns the last error when the
+// batch could not be delivered and was dead-lettered or dropped.
+func (p *WebhookPlugin) deliver(cfg config.UsageWebhook, body []byte, count int) error {
+	retries := defaultWebhookMaxRetries
+	if cfg.MaxRetries != nil {
+		retries = max(*cfg.MaxRetries, 0)
+	}
+	payload, err := compressPayload(cfg.Compression, body)
+	if err != nil {
+		log.Errorf("usage-webhook: failed to compress payload: %v", err)
+		payload, cfg.Compression = body, ""
+	}
+	attempts := 0
+	for {
+		if attempts > 0 {
+			time.Sleep(webhookRetryBaseDelay << (attempts - 1))
+		}
+		attempts++
+		if err = p.post(cfg, body, payload); err == nil {
+			p.stats.recordFlush(count)
+			return nil
+		}
+		log.Debugf("usage-webhook: delivery attempt %d failed: %v", attempts, err)
+		if attempts > retries || !retryableDeliveryError(err) {
+			break
+		}
+	}
+	log.Warnf("usage-webhook: delivery failed after %d attempts: %v", attempts, err)
+	p.stats.recordFailure(count, err)
+	if cfg.DeadLetterFile != "" {
+		if errDLQ := appendDeadLetter(cfg.DeadLetterFile, body); errDLQ != nil {
+			log.Errorf("usage-webhook: failed to write dead-letter file: %v", errDLQ)
+		}
+	}
+	return err
+}
+
+// post sends payload, the possibly compressed form of body. The signature always
+// covers the uncompressed JSON body.
+func (p *WebhookPlugin) post(cfg config.UsageWebhook, body, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Compression != "" {
+		req.Header.Set("Content-Encoding", cfg.Compression)
+	}
+	if cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write(body)
+		req.Header.Set("X-CLIProxy-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("usage-webhook: close response body error: %v", errClose)
+		}
+	}()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+func (p *WebhookPlugin) status() PluginStatus {
+	p.mu.Lock()
+	enabled, buffered := p.cfg.Enable, len(p.pending)
+	p.mu.Unlock()
+	return p.stats.snapshot("webhook", enabled, buffered)
+}
+
+func appendDeadLetter(path string, lines ...[]byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err = f.Write(append(line, '\n')); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func webhookBatchSize(cfg config.UsageWebhook) int {
+	if cfg.BatchSize > 1 {
+		return cfg.BatchSize
+	}
+	return 1
+}
@@ -576,7 +576,12 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			}
 		}
 
-		usage.StopDefault()
+		if err := usage.ShutdownDefault(ctx); err != nil {
+			log.Errorf("failed to flush usage plugins: %v", err)
+			if shutdownErr == nil {
+				shutdownErr = err
+			}
+		}
 	})
 	return shutdownErr
 }
@@ -2,6 +2,7 @@ package usage
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -16,8 +17,12 @@ type Record struct {
 	AuthID      string
 	Source      string
 	RequestedAt time.Time
-	Failed      bool
-	Detail      Detail
+	// Latency is the time from RequestedAt until the record was published. For
+	// streaming responses that is the first chunk carrying usage, which is often
+	// the end of the stream but not always, so it is not a full request duration.
+	Latency time.Duration
+	Failed  bool
+	Detail  Detail
 }
 
 // Detail holds the token usage breakdown.
@@ -34,6 +39,12 @@ type Plugin interface {
 	HandleUsage(ctx context.Context, record Record)
 }
 
+// Closer is implemented by plugins that buffer records and need to flush them
+// before the process exits.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
 type queueItem struct {
 	ctx    context.Context
 	record Record
@@ -44,6 +55,7 @@ type Manager struct {
 	once     sync.Once
 	stopOnce sync.Once
 	cancel   context.CancelFunc
+	done     chan struct{}
 
 	mu     sync.Mutex
 	cond   *sync.Cond
@@ -56,7 +68,7 @@ type Manager struct {
 
 // NewManager constructs a manager with a buffered queue.
 func NewManager(buffer int) *Manager {
-	m := &Manager{}
+	m := &Manager{done: make(chan struct{})}
 	m.cond = sync.NewCond(&m.mu)
 	return m
 }
@@ -92,6 +104,43 @@ func (m *Manager) Stop() {
 	})
 }
 
+// Shutdown stops the dispatcher, waits until the queued records have reached
+// the plugins and then closes every plugin that implements Closer. It returns
+// early with the context error when ctx is done first.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Starting a stopped manager only runs the dispatcher until the queue is
+	// empty, which guarantees that done is eventually closed.
+	m.Start(context.Background())
+	m.Stop()
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	m.pluginsMu.RLock()
+	plugins := make([]Plugin, len(m.plugins))
+	copy(plugins, m.plugins)
+	m.pluginsMu.RUnlock()
+	var errs []error
+	for _, plugin := range plugins {
+		closer, ok := plugin.(Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Register appends a plugin to the delivery list.
 func (m *Manager) Register(plugin Plugin) {
 	if m == nil || plugin == nil {
@@ -121,6 +170,7 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 }
 
 func (m *Manager) run(ctx context.Context) {
+	defer close(m.done)
 	for {
 		m.mu.Lock()
 		for !m.closed && len(m.queue) == 0 {
@@ -178,3 +228,6 @@ func StartDefault(ctx context.Context) { DefaultManager().Start(ctx) }
 
 // StopDefault stops the default manager's dispatcher.
 func StopDefault() { DefaultManager().Stop() }
+
+// ShutdownDefault drains the default manager and closes its plugins.
+func ShutdownDefault(ctx context.Context) error { return DefaultManager().Shutdown(ctx) }